@@ -0,0 +1,32 @@
+package gocrawl
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// cssUrlRe matches both url(...) functions (quoted or bare) and @import
+// statements, which may use either the url() form or a bare quoted string.
+var cssUrlRe = regexp.MustCompile(`url\(\s*['"]?([^'")]+)['"]?\s*\)|@import\s+['"]([^'"]+)['"]`)
+
+// cssLinkExtractor extracts outlinks from a stylesheet: url(...) references
+// (background images, fonts, etc.) and @import rules.
+type cssLinkExtractor struct{}
+
+func (cssLinkExtractor) CanExtract(contentType string) bool {
+	return contentType == "text/css"
+}
+
+func (cssLinkExtractor) Extract(body []byte, base *url.URL) []*Outlink {
+	var links []*Outlink
+	for _, m := range cssUrlRe.FindAllStringSubmatch(string(body), -1) {
+		ref := m[1]
+		if ref == "" {
+			ref = m[2]
+		}
+		if resolved := resolve(base, ref); resolved != nil {
+			links = append(links, &Outlink{resolved, "style", "url"})
+		}
+	}
+	return links
+}
@@ -0,0 +1,59 @@
+package gocrawl
+
+import (
+	"encoding/xml"
+	"net/url"
+)
+
+// rssFeed and atomFeed mirror just enough of RSS 2.0 and Atom to pull out
+// item/entry links; everything else is ignored.
+type rssFeed struct {
+	Items []struct {
+		Link string `xml:"link"`
+	} `xml:"channel>item"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		Links []struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+// feedLinkExtractor extracts per-item/entry links from an RSS or Atom feed.
+type feedLinkExtractor struct{}
+
+func (feedLinkExtractor) CanExtract(contentType string) bool {
+	switch contentType {
+	case "application/rss+xml", "application/atom+xml":
+		return true
+	}
+	return false
+}
+
+func (feedLinkExtractor) Extract(body []byte, base *url.URL) []*Outlink {
+	var links []*Outlink
+
+	var rss rssFeed
+	if xml.Unmarshal(body, &rss) == nil {
+		for _, item := range rss.Items {
+			if resolved := resolve(base, item.Link); resolved != nil {
+				links = append(links, &Outlink{resolved, "item", "link"})
+			}
+		}
+	}
+
+	var atom atomFeed
+	if xml.Unmarshal(body, &atom) == nil {
+		for _, entry := range atom.Entries {
+			for _, l := range entry.Links {
+				if resolved := resolve(base, l.Href); resolved != nil {
+					links = append(links, &Outlink{resolved, "entry", "href"})
+				}
+			}
+		}
+	}
+
+	return links
+}
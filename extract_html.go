@@ -0,0 +1,109 @@
+package gocrawl
+
+import (
+	"bytes"
+	"exp/html"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// htmlSources lists, for each HTML tag that can carry a link, the
+// attribute(s) to read it from.
+var htmlSources = []struct {
+	tag   string
+	attrs []string
+}{
+	{"a", []string{"href"}},
+	{"link", []string{"href"}},
+	{"img", []string{"src", "srcset"}},
+	{"script", []string{"src"}},
+	{"iframe", []string{"src"}},
+	{"source", []string{"src", "srcset"}},
+}
+
+// htmlLinkExtractor extracts outlinks from an HTML document: anchors,
+// stylesheet/resource links, images (including srcset), scripts, iframes,
+// media sources, <meta http-equiv=refresh> redirects, honoring a <base
+// href> element when present.
+type htmlLinkExtractor struct{}
+
+func (htmlLinkExtractor) CanExtract(contentType string) bool {
+	return contentType == "text/html" || contentType == "application/xhtml+xml"
+}
+
+func (htmlLinkExtractor) Extract(body []byte, base *url.URL) []*Outlink {
+	node, e := html.Parse(bytes.NewBuffer(body))
+	if e != nil {
+		return nil
+	}
+	doc := goquery.NewDocumentFromNode(node)
+	doc.Url = base
+
+	// A <base href> element, if any, overrides the response URL as the
+	// base for resolving every other relative reference on the page.
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if resolved := resolve(base, href); resolved != nil {
+			base = resolved
+		}
+	}
+
+	var links []*Outlink
+
+	for _, src := range htmlSources {
+		doc.Find(src.tag).Each(func(_ int, s *goquery.Selection) {
+			for _, attr := range src.attrs {
+				val, ok := s.Attr(attr)
+				if !ok || val == "" {
+					continue
+				}
+				if attr == "srcset" {
+					for _, u := range parseSrcset(val) {
+						if resolved := resolve(base, u); resolved != nil {
+							links = append(links, &Outlink{resolved, src.tag, attr})
+						}
+					}
+				} else if resolved := resolve(base, val); resolved != nil {
+					links = append(links, &Outlink{resolved, src.tag, attr})
+				}
+			}
+		})
+	}
+
+	doc.Find("meta[http-equiv]").Each(func(_ int, s *goquery.Selection) {
+		if !strings.EqualFold(s.AttrOr("http-equiv", ""), "refresh") {
+			return
+		}
+		// content is of the form "5; url=http://example.com/"
+		content := s.AttrOr("content", "")
+		parts := strings.SplitN(content, ";", 2)
+		if len(parts) != 2 {
+			return
+		}
+		target := strings.TrimSpace(parts[1])
+		if eq := strings.Index(target, "="); eq >= 0 && strings.EqualFold(target[:eq], "url") {
+			target = strings.TrimSpace(target[eq+1:])
+		}
+		target = strings.Trim(target, `'"`)
+		if resolved := resolve(base, target); resolved != nil {
+			links = append(links, &Outlink{resolved, "meta", "http-equiv"})
+		}
+	})
+
+	return links
+}
+
+// parseSrcset splits a srcset attribute value ("a.jpg 1x, b.jpg 2x") into
+// its candidate URLs, ignoring the descriptor (pixel density or width).
+func parseSrcset(val string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(val, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		urls = append(urls, strings.Fields(candidate)[0])
+	}
+	return urls
+}
@@ -0,0 +1,55 @@
+package gocrawl
+
+import (
+	"encoding/xml"
+	"net/url"
+)
+
+// sitemapUrlset and sitemapIndex mirror the handful of elements gocrawl
+// cares about in the sitemaps.org schema; everything else is ignored.
+type sitemapUrlset struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+}
+
+type sitemapIndex struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// sitemapLinkExtractor extracts the <loc> entries out of a sitemap.xml or
+// sitemapindex.xml document. It never matches on Content-Type directly -
+// "application/xml" and "text/xml" are ambiguous with feeds, so those are
+// routed here via genericXMLLinkExtractor sniffing the root element
+// instead.
+type sitemapLinkExtractor struct{}
+
+func (sitemapLinkExtractor) CanExtract(contentType string) bool {
+	return false
+}
+
+func (sitemapLinkExtractor) Extract(body []byte, base *url.URL) []*Outlink {
+	var links []*Outlink
+
+	var set sitemapUrlset
+	if xml.Unmarshal(body, &set) == nil {
+		for _, u := range set.URLs {
+			if resolved := resolve(base, u.Loc); resolved != nil {
+				links = append(links, &Outlink{resolved, "url", "loc"})
+			}
+		}
+	}
+
+	var idx sitemapIndex
+	if xml.Unmarshal(body, &idx) == nil {
+		for _, s := range idx.Sitemaps {
+			if resolved := resolve(base, s.Loc); resolved != nil {
+				links = append(links, &Outlink{resolved, "sitemap", "loc"})
+			}
+		}
+	}
+
+	return links
+}
@@ -0,0 +1,28 @@
+package gocrawl
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// plaintextUrlRe matches bare http(s) URLs in unstructured text.
+var plaintextUrlRe = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// plaintextLinkExtractor scrapes bare URLs out of a text/plain document,
+// which has no structure to rely on. It handles only "text/plain"; other
+// unrecognized Content-Types get no extractor at all.
+type plaintextLinkExtractor struct{}
+
+func (plaintextLinkExtractor) CanExtract(contentType string) bool {
+	return contentType == "text/plain"
+}
+
+func (plaintextLinkExtractor) Extract(body []byte, base *url.URL) []*Outlink {
+	var links []*Outlink
+	for _, match := range plaintextUrlRe.FindAllString(string(body), -1) {
+		if resolved := resolve(base, match); resolved != nil {
+			links = append(links, &Outlink{resolved, "", ""})
+		}
+	}
+	return links
+}
@@ -0,0 +1,45 @@
+package gocrawl
+
+import (
+	"bytes"
+	"encoding/xml"
+	"net/url"
+)
+
+// genericXMLLinkExtractor handles documents served under a generic XML
+// Content-Type ("application/xml" or "text/xml"), where sitemapLinkExtractor
+// and feedLinkExtractor can't be told apart by Content-Type alone: sitemaps
+// are commonly served as "application/xml", and more than a few feeds are
+// served that way too instead of "application/rss+xml"/"application/atom+xml".
+// It sniffs the document's root element to decide which of the two formats
+// it actually is.
+type genericXMLLinkExtractor struct{}
+
+func (genericXMLLinkExtractor) CanExtract(contentType string) bool {
+	return contentType == "application/xml" || contentType == "text/xml"
+}
+
+func (genericXMLLinkExtractor) Extract(body []byte, base *url.URL) []*Outlink {
+	switch xmlRootElement(body) {
+	case "urlset", "sitemapindex":
+		return sitemapLinkExtractor{}.Extract(body, base)
+	case "rss", "feed":
+		return feedLinkExtractor{}.Extract(body, base)
+	}
+	return nil
+}
+
+// xmlRootElement returns the local name of body's outermost element, or ""
+// if body isn't well-formed enough to have one.
+func xmlRootElement(body []byte) string {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, e := dec.Token()
+		if e != nil {
+			return ""
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package gocrawl
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Outlink is a single link discovered while scraping a fetched document. It
+// carries enough context about where it was found to let callers (and
+// Extender.Filter implementations, once Outlinks are threaded further) tell
+// navigational links (e.g. an <a href>) apart from embedded assets (e.g. an
+// <img src> or a CSS @import).
+type Outlink struct {
+	URL  *url.URL
+	Tag  string // e.g. "a", "img", "link", "script", or "style"/"item"/"entry"/"url"/"sitemap" for CSS, feeds and sitemaps; "" for plaintext
+	Attr string // e.g. "href", "src", or "url"/"link"/"loc" for CSS, feeds and sitemaps; "" for plaintext
+}
+
+// LinkExtractor scrapes the outgoing links from a single fetched document.
+// gocrawl selects an extractor based on the response's Content-Type, so
+// that archival crawls aren't limited to following <a href> in HTML pages.
+type LinkExtractor interface {
+	// CanExtract returns true if this extractor knows how to scrape links
+	// out of a document with the given (lowercased, parameters-stripped)
+	// Content-Type, e.g. "text/html" or "application/rss+xml".
+	CanExtract(contentType string) bool
+
+	// Extract parses the already-buffered body and returns the outlinks
+	// found, resolved against base.
+	Extract(body []byte, base *url.URL) []*Outlink
+}
+
+// defaultLinkExtractors is the set of extractors consulted by
+// worker.processLinks when the worker isn't configured with a more specific
+// list, covering HTML, CSS, syndication feeds (including feeds served under
+// a generic XML Content-Type, alongside sitemaps), and bare URLs in
+// plaintext. sitemapLinkExtractor isn't listed directly: it's only reached
+// through genericXMLLinkExtractor, which sniffs the root element to tell a
+// sitemap apart from a feed served with the same Content-Type.
+var defaultLinkExtractors = []LinkExtractor{
+	htmlLinkExtractor{},
+	cssLinkExtractor{},
+	feedLinkExtractor{},
+	genericXMLLinkExtractor{},
+	plaintextLinkExtractor{},
+}
+
+// extractorFor returns the first extractor among extractors (or, if nil,
+// defaultLinkExtractors) that handles contentType, or nil if none do. A
+// missing Content-Type falls back to "text/html", matching visitUrl's own
+// fallback for building a goquery document, rather than harvesting no
+// links at all.
+func extractorFor(extractors []LinkExtractor, contentType string) LinkExtractor {
+	if extractors == nil {
+		extractors = defaultLinkExtractors
+	}
+	ct := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if ct == "" {
+		ct = "text/html"
+	}
+	for _, x := range extractors {
+		if x.CanExtract(ct) {
+			return x
+		}
+	}
+	return nil
+}
+
+// isHTMLContentType reports whether contentType (as found in a raw
+// Content-Type header, parameters and all) is one visitUrl should parse
+// into a goquery document before handing it to the Extender.
+func isHTMLContentType(contentType string) bool {
+	return htmlLinkExtractor{}.CanExtract(strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])))
+}
+
+// resolve joins ref against base, returning nil (instead of an error) for a
+// ref that fails to parse, so extractors can filter with a single pass.
+func resolve(base *url.URL, ref string) *url.URL {
+	if ref == "" {
+		return nil
+	}
+	parsed, e := url.Parse(ref)
+	if e != nil {
+		return nil
+	}
+	return base.ResolveReference(parsed)
+}
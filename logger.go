@@ -0,0 +1,36 @@
+package gocrawl
+
+// Field is a single structured key/value pair attached to a log entry,
+// e.g. host, url, status, duration or worker index.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F builds a Field, meant to be used inline in a logging call:
+// this.logger.Info("fetched", F("host", this.host), F("status", res.StatusCode)).
+func F(key string, value interface{}) Field {
+	return Field{key, value}
+}
+
+// Logger is the pluggable, leveled, structured logging sink used
+// throughout a crawl. It replaces the old
+// logFunc func(LogFlags, string, ...interface{}) closure, which took a
+// fixed flag and a printf-style format string; LogFlags lives on as a
+// level filter on StdLogger, the default adapter, for backward
+// compatibility with code that configured it.
+type Logger interface {
+	Trace(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+// NopLogger discards every entry. Useful as a default when no logging is
+// wanted, without having to nil-check this.logger at every call site.
+type NopLogger struct{}
+
+func (NopLogger) Trace(string, ...Field) {}
+func (NopLogger) Info(string, ...Field)  {}
+func (NopLogger) Warn(string, ...Field)  {}
+func (NopLogger) Error(string, ...Field) {}
@@ -0,0 +1,23 @@
+package gocrawl
+
+import "github.com/sirupsen/logrus"
+
+// LogrusLogger adapts a *logrus.Logger to the Logger interface. logrus has
+// no dedicated trace-vs-debug split worth making here, so Trace maps to
+// logrus' own Trace level.
+type LogrusLogger struct {
+	L *logrus.Logger
+}
+
+func (l LogrusLogger) Trace(msg string, fields ...Field) { l.L.WithFields(toLogrusFields(fields)).Trace(msg) }
+func (l LogrusLogger) Info(msg string, fields ...Field)  { l.L.WithFields(toLogrusFields(fields)).Info(msg) }
+func (l LogrusLogger) Warn(msg string, fields ...Field)  { l.L.WithFields(toLogrusFields(fields)).Warn(msg) }
+func (l LogrusLogger) Error(msg string, fields ...Field) { l.L.WithFields(toLogrusFields(fields)).Error(msg) }
+
+func toLogrusFields(fields []Field) logrus.Fields {
+	f := make(logrus.Fields, len(fields))
+	for _, field := range fields {
+		f[field.Key] = field.Value
+	}
+	return f
+}
@@ -0,0 +1,31 @@
+package gocrawl
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger adapts a *slog.Logger to the Logger interface. slog has no
+// dedicated trace level, so Trace is logged one level below Debug.
+type SlogLogger struct {
+	L *slog.Logger
+}
+
+const levelTrace = slog.LevelDebug - 4
+
+func (l SlogLogger) Trace(msg string, fields ...Field) {
+	l.L.Log(context.Background(), levelTrace, msg, toAttrs(fields)...)
+}
+func (l SlogLogger) Info(msg string, fields ...Field)  { l.L.Info(msg, toAttrs(fields)...) }
+func (l SlogLogger) Warn(msg string, fields ...Field)  { l.L.Warn(msg, toAttrs(fields)...) }
+func (l SlogLogger) Error(msg string, fields ...Field) { l.L.Error(msg, toAttrs(fields)...) }
+
+// toAttrs flattens Fields into the key/value... varargs slog.Logger
+// methods expect.
+func toAttrs(fields []Field) []interface{} {
+	attrs := make([]interface{}, 0, len(fields)*2)
+	for _, f := range fields {
+		attrs = append(attrs, f.Key, f.Value)
+	}
+	return attrs
+}
@@ -0,0 +1,53 @@
+package gocrawl
+
+import (
+	"fmt"
+	"log"
+)
+
+// StdLogger is the default Logger adapter, writing to the standard "log"
+// package. Flags filters which levels are actually written, using the same
+// LogFlags bitmask gocrawl has always used to configure verbosity, so
+// existing callers that built a LogFlags value keep working unchanged.
+type StdLogger struct {
+	Flags LogFlags
+}
+
+func (l StdLogger) log(flag LogFlags, level, msg string, fields []Field) {
+	if l.Flags&flag == 0 {
+		return
+	}
+	log.Printf("%s %s%s", level, msg, formatFields(fields))
+}
+
+func (l StdLogger) Trace(msg string, fields ...Field) { l.log(LogTrace, "TRACE", msg, fields) }
+func (l StdLogger) Info(msg string, fields ...Field)  { l.log(LogInfo, "INFO", msg, fields) }
+func (l StdLogger) Warn(msg string, fields ...Field)  { l.log(LogIgnored, "WARN", msg, fields) }
+func (l StdLogger) Error(msg string, fields ...Field) { l.log(LogError, "ERROR", msg, fields) }
+
+// formatFields renders fields as " key=value key=value ...", or "" if
+// fields is empty.
+func formatFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	s := ""
+	for _, f := range fields {
+		s += " " + f.Key + "="
+		s += toString(f.Value)
+	}
+	return s
+}
+
+func toString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
@@ -0,0 +1,36 @@
+package gocrawl
+
+import "time"
+
+// Metrics is a sink for crawl counters and histograms - fetches, bytes
+// transferred, status-code buckets, per-host queue depth, and how the
+// actual crawl delay compared to the configured one - suitable for wiring
+// to Prometheus or any other metrics backend.
+type Metrics interface {
+	// IncFetches increments the fetch counter for host, bucketed by
+	// statusCode (e.g. 200, 404, 503).
+	IncFetches(host string, statusCode int)
+
+	// AddBytes adds n to the bytes-fetched counter for host.
+	AddBytes(host string, n int64)
+
+	// ObserveFetchDuration records how long a single fetch to host took.
+	ObserveFetchDuration(host string, d time.Duration)
+
+	// ObserveCrawlDelay records the configured crawl delay against the
+	// delay actually computed (which may differ once adaptive rate
+	// limiting is in play).
+	ObserveCrawlDelay(host string, configured, actual time.Duration)
+
+	// SetQueueDepth reports how many URLs are currently queued for host.
+	SetQueueDepth(host string, depth int)
+}
+
+// NopMetrics discards every observation.
+type NopMetrics struct{}
+
+func (NopMetrics) IncFetches(string, int)                                {}
+func (NopMetrics) AddBytes(string, int64)                                {}
+func (NopMetrics) ObserveFetchDuration(string, time.Duration)            {}
+func (NopMetrics) ObserveCrawlDelay(string, time.Duration, time.Duration) {}
+func (NopMetrics) SetQueueDepth(string, int)                             {}
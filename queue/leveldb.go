@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDBQueue is the default Queue implementation, backed by an embedded
+// LevelDB database so that queue state survives process restarts.
+//
+// Keys are laid out under three prefixes:
+//
+//	q/<host>/<url>        the pending Item, JSON-encoded
+//	inflight/<host>/<url> the Item leased out by Pop, moved here instead of
+//	                      deleted so a crash before Ack doesn't lose it
+//	seen/<host>/<url>     a one-byte marker, written the first time a URL for
+//	                      that host is pushed, and never removed, so that
+//	                      re-pushing an already-crawled (and since Acked) URL
+//	                      is a no-op.
+type LevelDBQueue struct {
+	db *leveldb.DB
+
+	mu  sync.Mutex
+	len map[string]int
+}
+
+// NewLevelDBQueue opens (creating if necessary) a LevelDB database at path
+// and returns a Queue backed by it. Any item left inflight by a prior
+// process that was killed or crashed between Pop and Ack is moved back
+// onto its host's pending queue, so resuming a crawl retries interrupted
+// work instead of losing it.
+func NewLevelDBQueue(path string) (*LevelDBQueue, error) {
+	db, e := leveldb.OpenFile(path, nil)
+	if e != nil {
+		return nil, e
+	}
+	q := &LevelDBQueue{db: db, len: make(map[string]int)}
+
+	if e := q.recoverInflight(); e != nil {
+		db.Close()
+		return nil, e
+	}
+
+	// Rebuild the in-memory per-host length counters from what is already
+	// on disk, so Len is correct immediately after resuming a crawl.
+	e = q.Range(func(it *Item) bool {
+		q.len[it.Host]++
+		return true
+	})
+	if e != nil {
+		db.Close()
+		return nil, e
+	}
+	return q, nil
+}
+
+// recoverInflight moves every item left under the inflight/ prefix - work
+// a prior process Popped but never Acked - back onto its host's pending
+// queue.
+func (q *LevelDBQueue) recoverInflight() error {
+	it := q.db.NewIterator(util.BytesPrefix([]byte("inflight/")), nil)
+	defer it.Release()
+
+	batch := new(leveldb.Batch)
+	for it.Next() {
+		var item Item
+		if e := json.Unmarshal(it.Value(), &item); e != nil {
+			return e
+		}
+		batch.Delete(append([]byte(nil), it.Key()...))
+		batch.Put(queueKey(item.Host, item.URL), append([]byte(nil), it.Value()...))
+	}
+	if e := it.Error(); e != nil {
+		return e
+	}
+	return q.db.Write(batch, nil)
+}
+
+func queueKey(host, url string) []byte {
+	return []byte(fmt.Sprintf("q/%s/%s", host, url))
+}
+
+func inflightKey(host, url string) []byte {
+	return []byte(fmt.Sprintf("inflight/%s/%s", host, url))
+}
+
+func seenKey(host, url string) []byte {
+	return []byte(fmt.Sprintf("seen/%s/%s", host, url))
+}
+
+func (q *LevelDBQueue) Push(item *Item) error {
+	sk := seenKey(item.Host, item.URL)
+	if _, e := q.db.Get(sk, nil); e == nil {
+		// Already seen: silently drop, this is expected of outlinks that
+		// were already crawled or are already queued.
+		return nil
+	} else if e != leveldb.ErrNotFound {
+		return e
+	}
+
+	b, e := json.Marshal(item)
+	if e != nil {
+		return e
+	}
+
+	batch := new(leveldb.Batch)
+	batch.Put(queueKey(item.Host, item.URL), b)
+	batch.Put(sk, []byte{1})
+	if e := q.db.Write(batch, nil); e != nil {
+		return e
+	}
+
+	q.mu.Lock()
+	q.len[item.Host]++
+	q.mu.Unlock()
+	return nil
+}
+
+// Requeue implements Queue. Unlike Push, it writes item unconditionally:
+// the "seen" marker was already set by the Push that first discovered
+// item.URL, and would otherwise make every backoff or Retry-After
+// re-enqueue a silent no-op.
+func (q *LevelDBQueue) Requeue(item *Item) error {
+	b, e := json.Marshal(item)
+	if e != nil {
+		return e
+	}
+
+	// Overwriting a copy that is already pending leaves Len unchanged.
+	qk := queueKey(item.Host, item.URL)
+	_, e = q.db.Get(qk, nil)
+	if e != nil && e != leveldb.ErrNotFound {
+		return e
+	}
+	pending := e == nil
+
+	batch := new(leveldb.Batch)
+	batch.Put(qk, b)
+	batch.Put(seenKey(item.Host, item.URL), []byte{1})
+	if e := q.db.Write(batch, nil); e != nil {
+		return e
+	}
+
+	if !pending {
+		q.mu.Lock()
+		q.len[item.Host]++
+		q.mu.Unlock()
+	}
+	return nil
+}
+
+// Pop implements Queue. The item is moved to the inflight/ prefix rather
+// than deleted outright, so a crash before the caller gets around to Ack
+// leaves it recoverable (see recoverInflight) instead of lost. Of the
+// eligible items, the one whose URL sorts first in key order is returned;
+// items are not popped in the order they were pushed.
+func (q *LevelDBQueue) Pop(host string) (*Item, bool, error) {
+	prefix := []byte(fmt.Sprintf("q/%s/", host))
+	it := q.db.NewIterator(util.BytesPrefix(prefix), nil)
+	defer it.Release()
+
+	now := time.Now()
+	for it.Next() {
+		var item Item
+		if e := json.Unmarshal(it.Value(), &item); e != nil {
+			return nil, false, e
+		}
+		if item.NextEligible.After(now) {
+			continue
+		}
+
+		key := append([]byte(nil), it.Key()...)
+		val := append([]byte(nil), it.Value()...)
+		batch := new(leveldb.Batch)
+		batch.Delete(key)
+		batch.Put(inflightKey(item.Host, item.URL), val)
+		if e := q.db.Write(batch, nil); e != nil {
+			return nil, false, e
+		}
+		q.mu.Lock()
+		q.len[host]--
+		q.mu.Unlock()
+		return &item, true, nil
+	}
+	return nil, false, it.Error()
+}
+
+// Ack implements Queue by deleting host/url's inflight lease, completing
+// the Pop/Ack handoff: only once Ack runs is the item gone for good.
+func (q *LevelDBQueue) Ack(host, url string) error {
+	return q.db.Delete(inflightKey(host, url), nil)
+}
+
+func (q *LevelDBQueue) Len(host string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.len[host], nil
+}
+
+func (q *LevelDBQueue) Range(fn func(*Item) bool) error {
+	it := q.db.NewIterator(util.BytesPrefix([]byte("q/")), nil)
+	defer it.Release()
+
+	for it.Next() {
+		var item Item
+		if e := json.Unmarshal(it.Value(), &item); e != nil {
+			return e
+		}
+		if !fn(&item) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+func (q *LevelDBQueue) Close() error {
+	return q.db.Close()
+}
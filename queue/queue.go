@@ -0,0 +1,83 @@
+// Package queue defines a pluggable, persistent crawl queue, used by the
+// crawler and its workers in place of the purely in-memory pending-count
+// bookkeeping, so that a long-running crawl can be interrupted and resumed
+// without re-visiting or losing track of URLs.
+package queue
+
+import "time"
+
+// Item is the persisted state tracked for a single queued URL.
+type Item struct {
+	URL      string
+	Host     string
+	Depth    int
+	Priority int
+
+	// NextEligible is the earliest time at which this item should be
+	// popped again, used to honor crawl-delay and backoff.
+	NextEligible time.Time
+
+	// Retries is the number of times this item has been re-enqueued after
+	// a transient fetch error.
+	Retries int
+
+	// LastErr is the error message from the most recent failed attempt,
+	// if any, kept for diagnostics.
+	LastErr string
+
+	// HeadRequest indicates the item should be fetched with a HEAD request
+	// first, mirroring the headRequest flag gocrawl already threads through
+	// worker.requestUrl.
+	HeadRequest bool
+}
+
+// Queue is implemented by crawl queue backends. Implementations must be
+// safe for concurrent use by multiple workers.
+type Queue interface {
+	// Push adds item to the queue as a newly discovered URL. If item.URL
+	// has already been seen (tracked via the implementation's own "seen"
+	// index), Push is a no-op and returns nil, so that callers can push
+	// candidate outlinks unconditionally without first checking for
+	// duplicates.
+	//
+	// Push must not be used to re-enqueue a URL this queue has already
+	// handed out via Pop (e.g. after a transient fetch error or a
+	// Retry-After response): the seen check would make it a no-op. Use
+	// Requeue for that instead.
+	Push(item *Item) error
+
+	// Requeue re-adds item to the pending queue for its host, bypassing
+	// the "seen" dedup check Push applies. It is for re-enqueueing a URL
+	// this queue already knows about - after a transient fetch error or a
+	// Retry-After response - and overwrites any copy of item.URL already
+	// pending for item.Host.
+	Requeue(item *Item) error
+
+	// Pop removes item from the pending queue for host - a queued item
+	// whose NextEligible time has passed; which one, when several are
+	// eligible, is up to the implementation - and leases it to the caller
+	// until Ack is called for it. ok is false if host
+	// currently has no eligible item (either empty, or every item is
+	// still waiting out its NextEligible time). An implementation must
+	// make a leased item recoverable (e.g. re-added to the pending queue)
+	// if the process exits before Ack is called, so that a crash between
+	// Pop and Ack doesn't lose the item.
+	Pop(host string) (item *Item, ok bool, err error)
+
+	// Ack releases the lease Pop placed on the item identified by host
+	// and url, the final step that retires it for good. It must be
+	// called once processing (success or failure) of an item returned by
+	// Pop completes.
+	Ack(host, url string) error
+
+	// Len returns the number of items currently queued for host.
+	Len(host string) (int, error)
+
+	// Range calls fn once for every queued item, in unspecified order,
+	// stopping early if fn returns false. Used by the crawler to re-seed
+	// workers with pending work on startup.
+	Range(fn func(*Item) bool) error
+
+	// Close releases any resources held by the queue.
+	Close() error
+}
@@ -0,0 +1,190 @@
+package gocrawl
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter computes how long a worker should wait before its next
+// request to a host. It is consulted by worker.setCrawlDelay in place of
+// Extender.ComputeDelay when the crawler is configured with one, and is fed
+// response signals via Observe so it can adapt as a crawl progresses,
+// rather than requiring users to hand-tune a fixed CrawlDelay.
+type RateLimiter interface {
+	// Delay returns how long to wait before the next request to host,
+	// given info (the user-configured and robots.txt-declared delays,
+	// plus the previously computed delay) and last (the most recent fetch
+	// on this host, or nil before the first one).
+	Delay(host string, info *DelayInfo, last *FetchInfo) time.Duration
+
+	// Observe folds the signals carried by a completed response (status
+	// code, Retry-After, and the draft-ietf-httpapis-ratelimit-headers
+	// RateLimit-* headers) into the limiter's per-host state, ahead of the
+	// next call to Delay for that host.
+	Observe(host string, res *http.Response)
+}
+
+// AIMDRateLimiter is the default RateLimiter. It applies an AIMD
+// (additive-increase / multiplicative-decrease) multiplier on top of the
+// configured crawl delay: backing off multiplicatively on a 429 or any 5xx
+// response, or a RateLimit-Remaining of zero, and recovering additively,
+// one step per successful (2xx/3xx) response, once things calm down. A
+// 4xx other than 429 leaves the multiplier alone, since a client error
+// says nothing about how hard it's safe to hit the host.
+type AIMDRateLimiter struct {
+	// Increase is the additive step by which a host's request rate
+	// recovers: it is subtracted from the host's delay multiplier after
+	// each successful response, never taking it below 1. Defaults to 0.1.
+	Increase float64
+	// DecreaseFactor cuts a host's request rate after a 429, a 5xx, or an
+	// exhausted rate-limit window: the host's delay multiplier is divided
+	// by it, up to MaxMultiplier. Defaults to 0.5, doubling the delay.
+	DecreaseFactor float64
+	// MaxMultiplier caps how large the multiplier (and so the effective
+	// delay, relative to the configured crawl delay) can grow. Defaults to
+	// 20.
+	MaxMultiplier float64
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimitState
+}
+
+// hostLimitState is the adaptive state tracked for a single host.
+type hostLimitState struct {
+	multiplier float64
+	retryAfter time.Time
+}
+
+func (rl *AIMDRateLimiter) state(host string) *hostLimitState {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.hosts == nil {
+		rl.hosts = make(map[string]*hostLimitState)
+	}
+	st, ok := rl.hosts[host]
+	if !ok {
+		st = &hostLimitState{multiplier: 1}
+		rl.hosts[host] = st
+	}
+	return st
+}
+
+func (rl *AIMDRateLimiter) increase() float64 {
+	if rl.Increase > 0 {
+		return rl.Increase
+	}
+	return 0.1
+}
+
+func (rl *AIMDRateLimiter) decreaseFactor() float64 {
+	if rl.DecreaseFactor > 0 {
+		return rl.DecreaseFactor
+	}
+	return 0.5
+}
+
+func (rl *AIMDRateLimiter) maxMultiplier() float64 {
+	if rl.MaxMultiplier > 0 {
+		return rl.MaxMultiplier
+	}
+	return 20
+}
+
+// Delay implements RateLimiter.
+func (rl *AIMDRateLimiter) Delay(host string, info *DelayInfo, last *FetchInfo) time.Duration {
+	base := info.OptsDelay
+	if info.RobotsDelay > base {
+		base = info.RobotsDelay
+	}
+
+	st := rl.state(host)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if !st.retryAfter.IsZero() {
+		if wait := st.retryAfter.Sub(time.Now()); wait > 0 {
+			return wait
+		}
+		st.retryAfter = time.Time{}
+	}
+
+	delay := time.Duration(float64(base) * st.multiplier)
+	// Never recommend less than the configured/robots.txt delay itself.
+	if delay < base {
+		delay = base
+	}
+	return delay
+}
+
+// Observe implements RateLimiter.
+func (rl *AIMDRateLimiter) Observe(host string, res *http.Response) {
+	st := rl.state(host)
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if wait, ok := retryAfterDuration(res); ok {
+		st.retryAfter = time.Now().Add(wait)
+	}
+	exhausted := false
+	if remaining, reset, ok := rateLimitHeaders(res); ok && remaining == 0 {
+		exhausted = true
+		if wait := reset.Sub(time.Now()); wait > st.retryAfter.Sub(time.Now()) {
+			st.retryAfter = reset
+		}
+	}
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 || exhausted:
+		st.multiplier /= rl.decreaseFactor()
+		if max := rl.maxMultiplier(); st.multiplier > max {
+			st.multiplier = max
+		}
+	case res.StatusCode < 400:
+		st.multiplier -= rl.increase()
+		if st.multiplier < 1 {
+			st.multiplier = 1
+		}
+	// A 4xx other than 429 is a client-side error, not a capacity signal:
+	// leave the multiplier where it is.
+	}
+}
+
+// retryAfterDuration reports the wait requested by res's Retry-After
+// header, if any - either "N" seconds or an HTTP-date.
+func retryAfterDuration(res *http.Response) (time.Duration, bool) {
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, e := strconv.Atoi(v); e == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, e := http.ParseTime(v); e == nil {
+		return t.Sub(time.Now()), true
+	}
+	return 0, false
+}
+
+// rateLimitHeaders reports the RateLimit-Remaining and RateLimit-Reset
+// values from res, per the draft-ietf-httpapis-ratelimit-headers headers,
+// if both are present and well-formed.
+func rateLimitHeaders(res *http.Response) (remaining int, reset time.Time, ok bool) {
+	rv := res.Header.Get("RateLimit-Remaining")
+	sv := res.Header.Get("RateLimit-Reset")
+	if rv == "" || sv == "" {
+		return 0, time.Time{}, false
+	}
+	remaining, e := strconv.Atoi(rv)
+	if e != nil {
+		return 0, time.Time{}, false
+	}
+	secs, e := strconv.Atoi(sv)
+	if e != nil {
+		return 0, time.Time{}, false
+	}
+	return remaining, time.Now().Add(time.Duration(secs) * time.Second), true
+}
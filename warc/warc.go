@@ -0,0 +1,214 @@
+// Package warc implements a minimal writer for the WARC 1.1 archive format
+// (ISO 28500:2017), suitable for recording the requests and responses made
+// during a gocrawl crawl so that the crawl can be replayed or preserved.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordType is the value of the WARC-Type header, as defined by the
+// WARC 1.1 specification.
+type RecordType string
+
+// The record types written by this package.
+const (
+	TypeWarcinfo RecordType = "warcinfo"
+	TypeRequest  RecordType = "request"
+	TypeResponse RecordType = "response"
+)
+
+const warcVersion = "WARC/1.1"
+
+// Options configures the behavior of a WarcWriter.
+type Options struct {
+	// MaxSize is the approximate maximum size in bytes a single WARC file
+	// is allowed to reach before the WarcWriter rotates to a new one. Zero
+	// disables rotation.
+	MaxSize int64
+
+	// Digest enables computation of the WARC-Payload-Digest and
+	// WARC-Block-Digest headers, using sha1.
+	Digest bool
+}
+
+// WarcWriter appends records to a sequence of gzip-per-record WARC files on
+// disk, where each record is compressed independently and the resulting
+// streams are concatenated, so that the files remain readable with zcat
+// and other standard WARC tooling. It is safe for concurrent use.
+type WarcWriter struct {
+	prefix string
+	opts   Options
+
+	mu      sync.Mutex
+	f       *os.File
+	written int64
+	seq     int
+}
+
+// NewWriter creates a WarcWriter that writes to files named
+// "<prefix>-NNNNN.warc.gz", rotating to the next sequence number once the
+// current file reaches opts.MaxSize. It immediately emits a warcinfo
+// record describing the writer.
+func NewWriter(prefix string, opts Options) (*WarcWriter, error) {
+	w := &WarcWriter{prefix: prefix, opts: opts}
+	if err := w.rotate(); err != nil {
+		return nil, err
+	}
+	if err := w.WriteWarcinfo("gocrawl"); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Close flushes and closes the current underlying file.
+func (w *WarcWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+func (w *WarcWriter) fileName() string {
+	return fmt.Sprintf("%s-%05d.warc.gz", w.prefix, w.seq)
+}
+
+// rotate closes the current file, if any, and opens the next one in the
+// sequence. Must be called with w.mu held, except on first call from
+// NewWriter.
+func (w *WarcWriter) rotate() error {
+	if w.f != nil {
+		if err := w.f.Close(); err != nil {
+			return err
+		}
+	}
+	w.seq++
+	f, err := os.OpenFile(w.fileName(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.written = 0
+	return nil
+}
+
+// WriteWarcinfo writes a warcinfo record identifying the software that
+// produced this WARC file.
+func (w *WarcWriter) WriteWarcinfo(software string) error {
+	body := []byte(fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\n", software))
+	return w.writeRecord(TypeWarcinfo, "", "", body, nil, "application/warc-fields")
+}
+
+// WriteRequest writes a "request" record for the given target URI, where
+// header is the raw HTTP request line and headers, and body is the
+// (possibly empty) request body. The returned WARC-Record-ID can be passed
+// as concurrentTo to WriteResponse to link the two records together.
+func (w *WarcWriter) WriteRequest(target *url.URL, header, body []byte) (recordID string, err error) {
+	recordID = newRecordID()
+	err = w.writeRecordWithID(recordID, TypeRequest, target.String(), "", append(header, body...), nil,
+		"application/http; msgtype=request")
+	return recordID, err
+}
+
+// WriteResponse writes a "response" record for the given target URI, where
+// header is the raw HTTP status line and headers, and body is the
+// response body. concurrentTo, if non-empty, is the WARC-Record-ID of the
+// request record this response answers, and is recorded as
+// WARC-Concurrent-To. WARC-Payload-Digest is computed over body alone,
+// distinct from WARC-Block-Digest which covers header+body.
+func (w *WarcWriter) WriteResponse(target *url.URL, header, body []byte, concurrentTo string) error {
+	return w.writeRecord(TypeResponse, target.String(), concurrentTo, append(header, body...), body,
+		"application/http; msgtype=response")
+}
+
+func (w *WarcWriter) writeRecord(typ RecordType, targetURI, concurrentTo string, block, payload []byte, contentType string) error {
+	return w.writeRecordWithID(newRecordID(), typ, targetURI, concurrentTo, block, payload, contentType)
+}
+
+// writeRecordWithID writes a single record. block is digested as a whole
+// for WARC-Block-Digest; payload, if non-nil, is the entity body alone and
+// is digested separately for WARC-Payload-Digest. Callers only pass a
+// non-nil payload for record types that carry a distinct payload (i.e.
+// "response"); "request" and "warcinfo" records have none.
+func (w *WarcWriter) writeRecordWithID(recordID string, typ RecordType, targetURI, concurrentTo string, block, payload []byte, contentType string) error {
+	var hdr bytes.Buffer
+	fmt.Fprintf(&hdr, "%s\r\n", warcVersion)
+	fmt.Fprintf(&hdr, "WARC-Type: %s\r\n", typ)
+	fmt.Fprintf(&hdr, "WARC-Record-ID: <urn:uuid:%s>\r\n", recordID)
+	fmt.Fprintf(&hdr, "WARC-Date: %s\r\n", time.Now().UTC().Format(time.RFC3339Nano))
+	if targetURI != "" {
+		fmt.Fprintf(&hdr, "WARC-Target-URI: %s\r\n", targetURI)
+	}
+	if concurrentTo != "" {
+		fmt.Fprintf(&hdr, "WARC-Concurrent-To: <urn:uuid:%s>\r\n", concurrentTo)
+	}
+	if w.opts.Digest {
+		blockSum := sha1.Sum(block)
+		fmt.Fprintf(&hdr, "WARC-Block-Digest: sha1:%s\r\n", base32.StdEncoding.EncodeToString(blockSum[:]))
+		if payload != nil {
+			payloadSum := sha1.Sum(payload)
+			fmt.Fprintf(&hdr, "WARC-Payload-Digest: sha1:%s\r\n", base32.StdEncoding.EncodeToString(payloadSum[:]))
+		}
+	}
+	fmt.Fprintf(&hdr, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(&hdr, "Content-Length: %d\r\n", len(block))
+	hdr.WriteString("\r\n")
+
+	return w.writeGzipRecord(hdr.Bytes(), block)
+}
+
+// writeGzipRecord gzips header+block as a single, independent gzip member
+// and appends it to the current file, rotating first if the file has
+// grown beyond Options.MaxSize.
+func (w *WarcWriter) writeGzipRecord(header, block []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.opts.MaxSize > 0 && w.written >= w.opts.MaxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(header); err != nil {
+		return err
+	}
+	if _, err := gz.Write(block); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(gz, "\r\n\r\n"); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	n, err := w.f.Write(buf.Bytes())
+	w.written += int64(n)
+	return err
+}
+
+// newRecordID returns a random UUID (v4) string, used as a WARC-Record-ID.
+func newRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -2,13 +2,16 @@ package gocrawl
 
 import (
 	"bytes"
+	"context"
 	"exp/html"
 	"github.com/PuerkitoBio/goquery"
+	"github.com/marcinsnt/gocrawl/queue"
+	"github.com/marcinsnt/gocrawl/warc"
 	"github.com/temoto/robotstxt.go"
 	"io/ioutil"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
-	"strings"
 	"sync"
 	"time"
 )
@@ -27,36 +30,91 @@ type worker struct {
 	// Communication channels and sync
 	push chan<- *workerResponse
 	pop  popChannel
-	stop chan bool
 	wg   *sync.WaitGroup
 	wait <-chan time.Time
 
+	// ctx is cancelled by Crawler.Run's own context to request an
+	// immediate shutdown: every wait in run/requestUrl/fetchUrl selects on
+	// ctx.Done() instead of blindly blocking, so a cancellation is never
+	// delayed by a pending crawl-delay or in-flight HTTP request.
+	ctx context.Context
+
 	// Config
 	crawlDelay  time.Duration
 	idleTTL     time.Duration
 	robotsGroup *robotstxt.Group
 
+	// fetchTimeout bounds a single fetch, unless hostFetchTimeouts has a
+	// more specific value for the target host. Zero means no timeout.
+	fetchTimeout      time.Duration
+	hostFetchTimeouts map[string]time.Duration
+
 	// Callbacks
 	extender Extender
-	logFunc  func(LogFlags, string, ...interface{})
+	logger   Logger
+	metrics  Metrics
+
+	// Archival
+	warcWriter *warc.WarcWriter
+
+	// Persistent queue, used for resumability and to re-enqueue URLs that
+	// fail with a transient error instead of dropping them. Nil means the
+	// crawler was configured with the legacy in-memory pop/push channels
+	// only, in which case queuing behavior is unchanged.
+	queue queue.Queue
+
+	// linkExtractors, if non-nil, overrides defaultLinkExtractors for this
+	// worker's calls to processLinks.
+	linkExtractors []LinkExtractor
+
+	// rateLimiter, if non-nil, is consulted by setCrawlDelay instead of
+	// Extender.ComputeDelay, and fed every response via Observe.
+	rateLimiter RateLimiter
 
 	// Implementation fields
 	lastFetch      *FetchInfo
 	lastCrawlDelay time.Duration
+	retries        map[string]int
 }
 
+// Base and cap for the exponential backoff applied to a URL that is
+// re-enqueued after a transient fetch error, when a persistent Queue is
+// configured.
+const (
+	retryBaseDelay = 180 * time.Second
+	retryMaxDelay  = 2 * time.Hour
+)
+
+// queuePollInterval bounds how long a URL re-enqueued via Requeue (a
+// transient-error backoff or a Retry-After response) can sit past its
+// NextEligible time before this worker notices and retries it. run() only
+// reads freshly dispatched URLs off this.pop; nothing else ever calls
+// this.queue.Pop, so without a periodic check a Requeue'd item would stay
+// in the persistent queue forever.
+const queuePollInterval = 5 * time.Second
+
 // Start crawling the host.
 func (this *worker) run() {
 	defer func() {
-		this.logFunc(LogInfo, "worker done.\n")
+		this.logger.Info("worker done", F("index", this.index))
 		this.wg.Done()
 	}()
 
+	// Poll the persistent queue for items that became eligible while this
+	// worker was otherwise occupied (backoff/Retry-After requeues), if one
+	// is configured.
+	var queueTick <-chan time.Time
+	if this.queue != nil {
+		ticker := time.NewTicker(queuePollInterval)
+		defer ticker.Stop()
+		queueTick = ticker.C
+	}
+
 	// Enter loop to process URLs until stop signal is received
 	for {
 		var idleChan <-chan time.Time
 
-		this.logFunc(LogInfo, "waiting for pop...\n")
+		this.logger.Trace("waiting for pop", F("host", this.host), F("index", this.index))
 
 		// Initialize the idle timeout channel, if required
 		if this.idleTTL > 0 {
@@ -64,21 +122,24 @@ func (this *worker) run() {
 		}
 
 		select {
-		case <-this.stop:
-			this.logFunc(LogInfo, "stop signal received.\n")
+		case <-this.ctx.Done():
+			this.logger.Info("context done, stopping", F("host", this.host))
 			return
 
 		case <-idleChan:
-			this.logFunc(LogInfo, "idle timeout received.\n")
+			this.logger.Info("idle timeout received", F("host", this.host))
 			this.sendResponse(nil, false, nil, true)
 			return
 
+		case <-queueTick:
+			this.drainQueue()
+
 		case batch := <-this.pop:
 
 			// Got a batch of urls to crawl, loop and check at each iteration if a stop 
 			// is received.
 			for _, cmd := range batch {
-				this.logFunc(LogInfo, "popped: %s\n", cmd.u.String())
+				this.logger.Trace("popped", F("url", cmd.u.String()))
 
 				if isRobotsTxtUrl(cmd.u) {
 					this.requestRobotsTxt(cmd.u)
@@ -90,11 +151,22 @@ func (this *worker) run() {
 					this.sendResponse(cmd.u, false, nil, false)
 				}
 
+				// Ack only once the URL has been fully processed and the response
+				// sent, so a crash mid-fetch leaves it in the queue for retry.
+				if this.queue != nil && !isRobotsTxtUrl(cmd.u) {
+					if e := this.queue.Ack(this.host, cmd.u.String()); e != nil {
+						this.logger.Error("acking url", F("url", cmd.u.String()), F("err", e))
+					}
+					if depth, e := this.queue.Len(this.host); e == nil {
+						this.metrics.SetQueueDepth(this.host, depth)
+					}
+				}
+
 				// No need to check for idle timeout here, no idling while looping through
 				// a batch of URLs.
 				select {
-				case <-this.stop:
-					this.logFunc(LogInfo, "stop signal received.\n")
+				case <-this.ctx.Done():
+					this.logger.Info("context done, stopping", F("host", this.host))
 					return
 				default:
 					// Nothing, just continue...
@@ -104,13 +176,53 @@ func (this *worker) run() {
 	}
 }
 
+// drainQueue pops and processes every item of this.queue currently
+// eligible for this.host - the URLs requeueWithBackoff and the
+// Retry-After path in requestUrl put back for retry - the same way run's
+// main loop processes a popped cmd, Acking each as it completes.
+func (this *worker) drainQueue() {
+	for {
+		item, ok, e := this.queue.Pop(this.host)
+		if e != nil {
+			this.logger.Error("popping queue", F("host", this.host), F("err", e))
+			return
+		}
+		if !ok {
+			return
+		}
+
+		u, e := url.Parse(item.URL)
+		if e != nil {
+			this.logger.Error("parsing queued url", F("url", item.URL), F("err", e))
+		} else if this.isAllowedPerRobotsPolicies(u) {
+			this.requestUrl(u, item.HeadRequest)
+		} else {
+			this.extender.Disallowed(u)
+			this.sendResponse(u, false, nil, false)
+		}
+
+		if e := this.queue.Ack(this.host, item.URL); e != nil {
+			this.logger.Error("acking url", F("url", item.URL), F("err", e))
+		}
+		if depth, e := this.queue.Len(this.host); e == nil {
+			this.metrics.SetQueueDepth(this.host, depth)
+		}
+
+		select {
+		case <-this.ctx.Done():
+			return
+		default:
+		}
+	}
+}
+
 // Checks if the given URL can be fetched based on robots.txt policies.
 func (this *worker) isAllowedPerRobotsPolicies(u *url.URL) bool {
 	if this.robotsGroup != nil {
 		// Is this URL allowed per robots.txt policy?
 		ok := this.robotsGroup.Test(u.Path)
 		if !ok {
-			this.logFunc(LogIgnored, "ignored on robots.txt policy: %s\n", u.String())
+			this.logger.Warn("ignored on robots.txt policy", F("url", u.String()))
 		}
 		return ok
 	}
@@ -136,23 +248,40 @@ func (this *worker) requestUrl(u *url.URL, headRequest bool) {
 			// Success, visit the URL
 			harvested = this.visitUrl(res)
 			visited = true
+		} else if retryWait, honored := retryAfterDuration(res); honored && this.queue != nil &&
+			(res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable) {
+			// A 429/503 with Retry-After is a request to slow down, not a
+			// hard failure: re-queue instead of dropping the URL.
+			this.logger.Info("honoring Retry-After", F("url", u.String()), F("wait", retryWait))
+			if e := this.queue.Requeue(&queue.Item{
+				URL:          u.String(),
+				Host:         this.host,
+				NextEligible: time.Now().Add(retryWait),
+				HeadRequest:  headRequest,
+			}); e != nil {
+				this.logger.Error("re-enqueueing after Retry-After", F("url", u.String()), F("err", e))
+			}
 		} else {
 			// Error based on status code received
 			this.extender.Error(newCrawlErrorMessage(res.Status, CekHttpStatusCode, u))
-			this.logFunc(LogError, "ERROR status code for %s: %s\n", u.String(), res.Status)
+			this.logger.Error("unexpected status code", F("url", u.String()), F("status", res.Status))
 		}
 		this.sendResponse(u, visited, harvested, false)
 
-		// Wait for crawl delay
-		<-wait
+		// Wait for crawl delay, unless the context is cancelled first so
+		// shutdown is immediate.
+		select {
+		case <-wait:
+		case <-this.ctx.Done():
+		}
 	}
 }
 
 // Process the robots.txt URL.
 func (this *worker) requestRobotsTxt(u *url.URL) {
 	// Ask if it should be fetched
-	if reqRob, robData := this.extender.RequestRobots(u, this.robotUserAgent); !reqRob {
-		this.logFunc(LogInfo, "using robots.txt from cache\n")
+	if reqRob, robData := this.extender.RequestRobots(this.ctx, u, this.robotUserAgent); !reqRob {
+		this.logger.Info("using robots.txt from cache", F("host", this.host))
 		this.robotsGroup = this.getRobotsTxtGroup(robData, nil)
 
 	} else {
@@ -167,8 +296,11 @@ func (this *worker) requestRobotsTxt(u *url.URL) {
 
 			this.robotsGroup = this.getRobotsTxtGroup(nil, res)
 
-			// Wait for crawl delay
-			<-wait
+			// Wait for crawl delay, unless the context is cancelled first.
+			select {
+			case <-wait:
+			case <-this.ctx.Done():
+			}
 		}
 	}
 }
@@ -196,13 +328,42 @@ func (this *worker) getRobotsTxtGroup(b []byte, res *http.Response) (g *robotstx
 	// robots.txt is similar behavior.
 	if e != nil {
 		this.extender.Error(newCrawlError(e, CekParseRobots, nil))
-		this.logFunc(LogError, "ERROR parsing robots.txt for host %s: %s\n", this.host, e.Error())
+		this.logger.Error("parsing robots.txt", F("host", this.host), F("err", e))
 	} else {
 		g = data.FindGroup(this.robotUserAgent)
 	}
 	return
 }
 
+// Push u back onto the persistent queue, delaying its next eligible pop
+// time by retryBaseDelay, doubled for each previous retry and capped at
+// retryMaxDelay.
+func (this *worker) requeueWithBackoff(u *url.URL, headRequest bool, lastErr string) {
+	if this.retries == nil {
+		this.retries = make(map[string]int)
+	}
+	key := u.String()
+	n := this.retries[key]
+	this.retries[key] = n + 1
+
+	delay := retryBaseDelay << uint(n)
+	if delay <= 0 || delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	item := &queue.Item{
+		URL:          key,
+		Host:         this.host,
+		Retries:      n + 1,
+		NextEligible: time.Now().Add(delay),
+		LastErr:      lastErr,
+		HeadRequest:  headRequest,
+	}
+	if e := this.queue.Requeue(item); e != nil {
+		this.logger.Error("re-enqueueing after fetch failure", F("url", key), F("err", e))
+	}
+}
+
 // Set the crawl delay between this request and the next.
 func (this *worker) setCrawlDelay() {
 	var robDelay time.Duration
@@ -210,12 +371,24 @@ func (this *worker) setCrawlDelay() {
 	if this.robotsGroup != nil {
 		robDelay = this.robotsGroup.CrawlDelay
 	}
-	this.lastCrawlDelay = this.extender.ComputeDelay(this.host,
-		&DelayInfo{this.crawlDelay,
-			robDelay,
-			this.lastCrawlDelay},
-		this.lastFetch)
-	this.logFunc(LogInfo, "using crawl-delay: %v\n", this.lastCrawlDelay)
+	info := &DelayInfo{this.crawlDelay, robDelay, this.lastCrawlDelay}
+	if this.rateLimiter != nil {
+		this.lastCrawlDelay = this.rateLimiter.Delay(this.host, info, this.lastFetch)
+	} else {
+		this.lastCrawlDelay = this.extender.ComputeDelay(this.host, info, this.lastFetch)
+	}
+	this.logger.Info("using crawl-delay", F("host", this.host), F("duration", this.lastCrawlDelay))
+	this.metrics.ObserveCrawlDelay(this.host, this.crawlDelay, this.lastCrawlDelay)
+}
+
+// fetchTimeoutFor returns the per-fetch timeout to apply for host: its
+// entry in hostFetchTimeouts if any, otherwise the global fetchTimeout.
+// Zero means no timeout.
+func (this *worker) fetchTimeoutFor(host string) time.Duration {
+	if d, ok := this.hostFetchTimeouts[host]; ok {
+		return d
+	}
+	return this.fetchTimeout
 }
 
 // Request the specified URL and return the response.
@@ -223,27 +396,48 @@ func (this *worker) fetchUrl(u *url.URL, agent string, headRequest bool) (res *h
 	var e error
 
 	for {
-		// Wait for crawl delay, if one is pending.
-		this.logFunc(LogTrace, "waiting for crawl delay\n")
+		// Wait for crawl delay, if one is pending, unless the context is
+		// cancelled first so shutdown is never delayed by it.
+		this.logger.Trace("waiting for crawl delay", F("host", this.host))
 		if this.wait != nil {
-			<-this.wait
+			select {
+			case <-this.wait:
+			case <-this.ctx.Done():
+				return nil, false
+			}
 			this.wait = nil
 		}
 
 		// Compute the next delay
 		this.setCrawlDelay()
 
-		// Compute the fetch duration
-		now := time.Now()
+		// Mark the start of the fetch so its duration can be measured below.
+		start := time.Now()
+
+		// Derive a per-fetch context, bounded by the global or per-host
+		// fetch timeout, so a hung request is cancelled rather than
+		// blocking this worker until the OS gives up on it.
+		fetchCtx := this.ctx
+		if d := this.fetchTimeoutFor(u.Host); d > 0 {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithTimeout(this.ctx, d)
+			defer cancel()
+		}
 
 		// Request the URL
-		if res, e = this.extender.Fetch(u, agent, headRequest); e != nil {
+		if res, e = this.extender.Fetch(fetchCtx, u, agent, headRequest); e != nil {
 			// No fetch, so set to nil
 			this.lastFetch = nil
 
 			// Notify error
 			this.extender.Error(newCrawlError(e, CekFetch, u))
-			this.logFunc(LogError, "ERROR fetching %s: %s\n", u.String(), e.Error())
+			this.logger.Error("fetching url", F("url", u.String()), F("err", e))
+
+			// Transient error: re-enqueue with exponential backoff instead of
+			// dropping the URL, if a persistent queue is configured.
+			if this.queue != nil && !isRobotsTxtUrl(u) {
+				this.requeueWithBackoff(u, headRequest, e.Error())
+			}
 
 			// Return from this URL crawl
 			this.sendResponse(u, false, nil, false)
@@ -251,12 +445,35 @@ func (this *worker) fetchUrl(u *url.URL, agent string, headRequest bool) (res *h
 
 		} else {
 			// Get the fetch duration
-			fetchDuration := now.Sub(time.Now())
+			fetchDuration := time.Since(start)
 			// Crawl delay starts now.
 			this.wait = time.After(this.lastCrawlDelay)
 
 			// Keep trace of this last fetch info
 			this.lastFetch = &FetchInfo{fetchDuration, res.StatusCode, headRequest, isRobotsTxtUrl(u)}
+
+			this.metrics.IncFetches(this.host, res.StatusCode)
+			this.metrics.ObserveFetchDuration(this.host, fetchDuration)
+			if res.ContentLength > 0 {
+				this.metrics.AddBytes(this.host, res.ContentLength)
+			}
+
+			// A successful fetch clears any backoff accumulated by earlier
+			// transient errors for this URL.
+			if this.retries != nil {
+				delete(this.retries, u.String())
+			}
+
+			// Feed the response's status code and rate-limit signals back
+			// into the adaptive rate limiter, if one is configured.
+			if this.rateLimiter != nil {
+				this.rateLimiter.Observe(this.host, res)
+			}
+
+			// Archive the exchange, if a WARC writer is configured
+			if this.warcWriter != nil {
+				this.writeWarcRecord(res)
+			}
 		}
 
 		if headRequest {
@@ -277,6 +494,41 @@ func (this *worker) fetchUrl(u *url.URL, agent string, headRequest bool) (res *h
 	return
 }
 
+// Buffer the response body (rewinding res.Body so that it can still be
+// consumed downstream by visitUrl/getRobotsTxtGroup, exactly as if no WARC
+// writer were configured) and append a request/response record pair to the
+// WARC file.
+func (this *worker) writeWarcRecord(res *http.Response) {
+	body, e := ioutil.ReadAll(res.Body)
+	if e != nil {
+		this.logger.Error("reading body for WARC capture", F("url", res.Request.URL.String()), F("err", e))
+		return
+	}
+	// Rewind the res.Body (by re-creating it from the bytes), same pattern as
+	// getRobotsTxtGroup/visitUrl use for their own re-reads.
+	res.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+
+	reqHeader, e := httputil.DumpRequest(res.Request, false)
+	if e != nil {
+		this.logger.Error("dumping request for WARC capture", F("url", res.Request.URL.String()), F("err", e))
+		return
+	}
+	recordID, e := this.warcWriter.WriteRequest(res.Request.URL, reqHeader, nil)
+	if e != nil {
+		this.logger.Error("writing WARC request record", F("url", res.Request.URL.String()), F("err", e))
+		return
+	}
+
+	respHeader, e := httputil.DumpResponse(res, false)
+	if e != nil {
+		this.logger.Error("dumping response for WARC capture", F("url", res.Request.URL.String()), F("err", e))
+		return
+	}
+	if e := this.warcWriter.WriteResponse(res.Request.URL, respHeader, body, recordID); e != nil {
+		this.logger.Error("writing WARC response record", F("url", res.Request.URL.String()), F("err", e))
+	}
+}
+
 // Send a response to the crawler.
 func (this *worker) sendResponse(u *url.URL, visited bool, harvested []*url.URL, idleDeath bool) {
 	// Push harvested urls back to crawler, even if empty (uses the channel communication
@@ -292,32 +544,38 @@ func (this *worker) visitUrl(res *http.Response) []*url.URL {
 	var doc *goquery.Document
 	var harvested []*url.URL
 	var doLinks bool
+	var body []byte
 
-	// Load a goquery document and call the visitor function
+	// Load a goquery document, for HTML content only, and call the visitor
+	// function.
 	if bd, e := ioutil.ReadAll(res.Body); e != nil {
 		this.extender.Error(newCrawlError(e, CekReadBody, res.Request.URL))
-		this.logFunc(LogError, "ERROR reading body %s: %s\n", res.Request.URL.String(), e.Error())
+		this.logger.Error("reading body", F("url", res.Request.URL.String()), F("err", e))
 	} else {
-		if node, e := html.Parse(bytes.NewBuffer(bd)); e != nil {
-			this.extender.Error(newCrawlError(e, CekParseBody, res.Request.URL))
-			this.logFunc(LogError, "ERROR parsing %s: %s\n", res.Request.URL.String(), e.Error())
-		} else {
-			doc = goquery.NewDocumentFromNode(node)
-			doc.Url = res.Request.URL
+		body = bd
+		// A missing Content-Type is treated as HTML rather than skipped,
+		// matching the behavior before link extraction started dispatching
+		// on Content-Type at all: plenty of servers omit the header on
+		// otherwise-ordinary HTML pages.
+		contentType := res.Header.Get("Content-Type")
+		if contentType == "" || isHTMLContentType(contentType) {
+			if node, e := html.Parse(bytes.NewBuffer(bd)); e != nil {
+				this.extender.Error(newCrawlError(e, CekParseBody, res.Request.URL))
+				this.logger.Error("parsing body", F("url", res.Request.URL.String()), F("err", e))
+			} else {
+				doc = goquery.NewDocumentFromNode(node)
+				doc.Url = res.Request.URL
+			}
 		}
 		// Re-assign the body so it can be consumed by the visitor function
 		res.Body = ioutil.NopCloser(bytes.NewBuffer(bd))
 	}
 
-	// Visit the document (with nil goquery doc if failed to load)
-	if harvested, doLinks = this.extender.Visit(res, doc); doLinks {
-		// Links were not processed by the visitor, so process links
-		if doc != nil {
-			harvested = this.processLinks(doc)
-		} else {
-			this.extender.Error(newCrawlErrorMessage("No goquery document to process links.", CekProcessLinks, res.Request.URL))
-			this.logFunc(LogError, "ERROR processing links %s\n", res.Request.URL.String())
-		}
+	// Visit the document (with nil goquery doc for non-HTML content, or if
+	// it failed to load)
+	if harvested, doLinks = this.extender.Visit(this.ctx, res, doc); doLinks {
+		// Links were not processed by the visitor, so process them ourselves
+		harvested = this.processLinks(res, body)
 	}
 	// Notify that this URL has been visited
 	this.extender.Visited(res.Request.URL, harvested)
@@ -325,22 +583,23 @@ func (this *worker) visitUrl(res *http.Response) []*url.URL {
 	return harvested
 }
 
-// Scrape the document's content to gather all links
-func (this *worker) processLinks(doc *goquery.Document) (result []*url.URL) {
-	urls := doc.Find("a[href]").Map(func(_ int, s *goquery.Selection) string {
-		val, _ := s.Attr("href")
-		return val
-	})
-	for _, s := range urls {
-		// If href starts with "#", then it points to this same exact URL, ignore (will fail to parse anyway)
-		if len(s) > 0 && !strings.HasPrefix(s, "#") {
-			if parsed, e := url.Parse(s); e == nil {
-				parsed = doc.Url.ResolveReference(parsed)
-				result = append(result, parsed)
-			} else {
-				this.logFunc(LogIgnored, "ignore on unparsable policy %s: %s\n", s, e.Error())
-			}
-		}
+// Scrape the response's content to gather all outgoing links, dispatching
+// to the LinkExtractor registered for its Content-Type (see
+// defaultLinkExtractors), rather than only looking at HTML <a href>s.
+//
+// This flattens each Outlink down to its URL because Extender.Visit,
+// Extender.Visited and workerResponse all carry harvested links as
+// []*url.URL; threading Tag/Attr any further needs those signatures
+// widened too.
+func (this *worker) processLinks(res *http.Response, body []byte) (result []*url.URL) {
+	contentType := res.Header.Get("Content-Type")
+	x := extractorFor(this.linkExtractors, contentType)
+	if x == nil {
+		this.logger.Warn("no link extractor for content-type", F("contentType", contentType), F("url", res.Request.URL.String()))
+		return nil
+	}
+	for _, outlink := range x.Extract(body, res.Request.URL) {
+		result = append(result, outlink.URL)
 	}
 	return
 }